@@ -0,0 +1,82 @@
+// Command homemendi-local serves the API on one HTTP server, so that the
+// frontend can be developed without deploying. Each request is turned into
+// the event API Gateway would send, authorized by the authorizer lambda's
+// handler and passed to the handler of its route.
+//
+//	homemendi-local [-addr :8080] [-endpoint http://localhost:8000] [-create-tables]
+//
+// Run it against DynamoDB Local and point the frontend at it. Any
+// credentials are accepted by DynamoDB Local; tokens are verified against
+// the trusted issuers as in production, so signing in works as usual:
+//
+//	docker run -p 8000:8000 amazon/dynamodb-local
+//	AWS_REGION=us-east-1 AWS_ACCESS_KEY_ID=local AWS_SECRET_ACCESS_KEY=local \
+//		homemendi-local -endpoint http://localhost:8000 -create-tables
+//	VITE_API_URL=http://localhost:8080 npm run dev
+//
+// With -memory, projects, houses and settings are kept in memory and lost
+// when the command exits. The authorizer still reads its tables from
+// DynamoDB.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/hnucamendi/house-hunting/backend/lib/api"
+	"github.com/hnucamendi/house-hunting/backend/lib/authorizer"
+	"github.com/hnucamendi/house-hunting/backend/lib/gateway"
+	"github.com/hnucamendi/house-hunting/backend/lib/repository"
+	"github.com/hnucamendi/house-hunting/backend/lib/units"
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "address to listen on")
+	endpoint := flag.String("endpoint", "", "DynamoDB endpoint, such as http://localhost:8000 for DynamoDB Local")
+	createTables := flag.Bool("create-tables", false, "create the tables the API uses if they do not exist")
+	memory := flag.Bool("memory", false, "keep projects, houses and settings in memory instead of DynamoDB")
+	origin := flag.String("allow-origin", "*", "origin allowed to call the API from a browser")
+	flag.Parse()
+
+	ctx := context.Background()
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		log.Fatalf("Failed to load SDK configuration: %v", err)
+	}
+
+	db := dynamodb.NewFromConfig(cfg, func(o *dynamodb.Options) {
+		if *endpoint != "" {
+			o.BaseEndpoint = aws.String(*endpoint)
+		}
+	})
+
+	if *createTables {
+		if err := ensureTables(ctx, db); err != nil {
+			log.Fatalf("Failed to create tables: %v", err)
+		}
+	}
+
+	rates, err := units.RatesFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to load exchange rates: %v", err)
+	}
+
+	authorizer.Configure(db, ssm.NewFromConfig(cfg))
+
+	var store repository.ProjectStore = repository.New(db)
+	if *memory {
+		store = repository.NewMemoryStore()
+	}
+
+	gw := gateway.New(api.New(store, rates).Routes(), authorizer.HandleRequest, *origin)
+
+	log.Printf("Serving the API on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, gw))
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// tables are the tables the API and its authorizer read and write, with the
+// keys they are deployed with.
+var tables = []*dynamodb.CreateTableInput{
+	{
+		TableName: aws.String("UsersTable"),
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String("id"), KeyType: types.KeyTypeHash},
+			{AttributeName: aws.String("projectId"), KeyType: types.KeyTypeRange},
+		},
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String("id"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("projectId"), AttributeType: types.ScalarAttributeTypeS},
+		},
+	},
+	{
+		TableName: aws.String("TokenRevocationsTable"),
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String("id"), KeyType: types.KeyTypeHash},
+		},
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String("id"), AttributeType: types.ScalarAttributeTypeS},
+		},
+	},
+	{
+		TableName: aws.String("RateLimitsTable"),
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String("id"), KeyType: types.KeyTypeHash},
+		},
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String("id"), AttributeType: types.ScalarAttributeTypeS},
+		},
+	},
+	{
+		TableName: aws.String("ApiKeysTable"),
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String("keyId"), KeyType: types.KeyTypeHash},
+		},
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String("keyId"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("ownerSub"), AttributeType: types.ScalarAttributeTypeS},
+		},
+		GlobalSecondaryIndexes: []types.GlobalSecondaryIndex{
+			{
+				IndexName: aws.String("ownerSub-index"),
+				KeySchema: []types.KeySchemaElement{
+					{AttributeName: aws.String("ownerSub"), KeyType: types.KeyTypeHash},
+				},
+				Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+			},
+		},
+	},
+}
+
+// ensureTables creates the tables that do not exist yet.
+func ensureTables(ctx context.Context, db *dynamodb.Client) error {
+	for _, table := range tables {
+		table.BillingMode = types.BillingModePayPerRequest
+
+		_, err := db.CreateTable(ctx, table)
+		var inUse *types.ResourceInUseException
+		if errors.As(err, &inUse) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %v", *table.TableName, err)
+		}
+
+		log.Printf("Created %s", *table.TableName)
+	}
+
+	return nil
+}
@@ -0,0 +1,562 @@
+// Command homemendi-migrate-userids moves UsersTable items from the legacy
+// user id, an MD5 hash of the user's email address, to their Cognito sub:
+// projects, the houses split off from them, and profiles.
+//
+// It runs against the live table. The lambdas read both ids until the
+// migration is done, and every item is moved in a transaction that only
+// succeeds if the item has not changed since it was read, so a concurrent
+// write is retried rather than lost. A project's houses are moved right after
+// it, and then given the project's expiry, in case it was trashed or restored
+// in between. A legacy profile whose owner already has one under the sub is
+// merged into it.
+//
+//	homemendi-migrate-userids -user-pool us-east-1_XXXXXXXXX [-dry-run]
+//
+// Once a run moves everything, drop legacyUserIds from the authorizer's
+// trusted issuers so the lambdas stop reading the legacy ids.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/hnucamendi/house-hunting/backend/lib/domain"
+	"github.com/hnucamendi/house-hunting/backend/lib/migrate"
+)
+
+const (
+	userPoolEnv = "HOMEMENDI_COGNITO_USER_POOL_ID"
+
+	// maxAttempts bounds how often an item that keeps changing underneath the
+	// migration is re-read before it is left for a later run.
+	maxAttempts = 5
+
+	// The prefixes of house and profile partitions, as laid out by the
+	// repository package.
+	projectPrefix = "PROJECT#"
+	profilePrefix = "USER#"
+)
+
+// client is the part of the DynamoDB API the migration uses.
+type client interface {
+	dynamodb.ScanAPIClient
+	dynamodb.QueryAPIClient
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+	DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+	TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error)
+}
+
+var (
+	db      client
+	cognito *cognitoidentityprovider.Client
+)
+
+// Stats count the legacy items by what became of them. Superseded items had
+// a copy under the sub already and were deleted; merged profiles were added
+// to the sub's.
+type Stats struct {
+	Migrated   int
+	Superseded int
+	Merged     int
+	Unmatched  int
+	Failed     int
+}
+
+// legacyIds maps the legacy id of every user in the pool to their sub.
+func legacyIds(ctx context.Context, userPoolId string) (map[string]string, error) {
+	ids := map[string]string{}
+
+	paginator := cognitoidentityprovider.NewListUsersPaginator(cognito, &cognitoidentityprovider.ListUsersInput{
+		UserPoolId: aws.String(userPoolId),
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list users: %v", err)
+		}
+
+		for _, u := range page.Users {
+			var sub, email string
+			for _, attr := range u.Attributes {
+				switch aws.ToString(attr.Name) {
+				case "sub":
+					sub = aws.ToString(attr.Value)
+				case "email":
+					email = aws.ToString(attr.Value)
+				}
+			}
+
+			if sub != "" && email != "" {
+				ids[domain.LegacyUserId(email)] = sub
+			}
+		}
+	}
+
+	return ids, nil
+}
+
+// owner splits the partition key of a project, house or profile item into
+// the prefix before the owner's id, the owner's id and what follows it.
+func owner(id string) (string, string, string) {
+	if rest, ok := strings.CutPrefix(id, projectPrefix); ok {
+		ownerId, projectId, _ := strings.Cut(rest, "#")
+		return projectPrefix, ownerId, "#" + projectId
+	}
+
+	if ownerId, ok := strings.CutPrefix(id, profilePrefix); ok {
+		return profilePrefix, ownerId, ""
+	}
+
+	return "", id, ""
+}
+
+// moveItem copies the item to the partition key id, owned by sub, and
+// deletes the legacy item in one transaction. It reports whether the item
+// was moved, and whether it had changed since it was read.
+func moveItem(ctx context.Context, table, id, sub string, item map[string]types.AttributeValue) (bool, bool, error) {
+	moved := make(map[string]types.AttributeValue, len(item))
+	for k, v := range item {
+		moved[k] = v
+	}
+	moved["id"] = &types.AttributeValueMemberS{Value: id}
+	if _, ok := item["ownerId"]; ok {
+		moved["ownerId"] = &types.AttributeValueMemberS{Value: sub}
+	}
+
+	condition, names, values := migrate.UnchangedCondition(item)
+
+	_, err := db.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{
+				Put: &types.Put{
+					TableName:           aws.String(table),
+					Item:                moved,
+					ConditionExpression: aws.String("attribute_not_exists(id)"),
+				},
+			},
+			{
+				Delete: &types.Delete{
+					TableName:                 aws.String(table),
+					Key:                       key(item),
+					ConditionExpression:       aws.String(condition),
+					ExpressionAttributeNames:  names,
+					ExpressionAttributeValues: values,
+				},
+			},
+		},
+	})
+	if err == nil {
+		return true, false, nil
+	}
+
+	var canceled *types.TransactionCanceledException
+	if !errors.As(err, &canceled) || len(canceled.CancellationReasons) != 2 {
+		return false, false, err
+	}
+
+	if aws.ToString(canceled.CancellationReasons[1].Code) == "ConditionalCheckFailed" {
+		return false, true, nil
+	}
+
+	if aws.ToString(canceled.CancellationReasons[0].Code) == "ConditionalCheckFailed" {
+		return false, false, nil
+	}
+
+	return false, false, err
+}
+
+// deleteLegacy removes a legacy project or house whose copy already exists
+// under the sub. The lambdas read and write the sub's copy once it exists, so
+// the legacy one is stale. It reports whether the item was deleted, which it
+// is not if it changed since it was read.
+func deleteLegacy(ctx context.Context, table, target string, item map[string]types.AttributeValue) (bool, error) {
+	condition, names, values := migrate.UnchangedCondition(item)
+
+	_, err := db.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName:                 aws.String(table),
+		Key:                       key(item),
+		ConditionExpression:       aws.String(condition),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+	})
+
+	var conditionErr *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionErr) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// mergeProfile adds the settings of a legacy profile to the sub's profile at
+// target, keeping those the sub's already has, and deletes the legacy one.
+// It reports whether it did, which it does not if either profile changed
+// since it was read.
+func mergeProfile(ctx context.Context, table, target string, item map[string]types.AttributeValue) (bool, error) {
+	out, err := db.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(table),
+		Key: map[string]types.AttributeValue{
+			"id":        &types.AttributeValueMemberS{Value: target},
+			"projectId": item["projectId"],
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil || out.Item == nil {
+		return false, err
+	}
+	existing := out.Item
+
+	// MergeSettings changes the settings it is given, which the condition
+	// must still compare against the profile as it was read.
+	merged := make(map[string]types.AttributeValue, len(existing))
+	for k, v := range existing {
+		merged[k] = v
+	}
+	if settings, ok := existing["settings"].(*types.AttributeValueMemberM); ok {
+		copied := make(map[string]types.AttributeValue, len(settings.Value))
+		for k, v := range settings.Value {
+			copied[k] = v
+		}
+		merged["settings"] = &types.AttributeValueMemberM{Value: copied}
+	}
+	migrate.MergeSettings(merged, item)
+
+	existingCondition, existingNames, existingValues := migrate.UnchangedCondition(existing)
+	condition, names, values := migrate.UnchangedCondition(item)
+
+	_, err = db.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{
+				Put: &types.Put{
+					TableName:                 aws.String(table),
+					Item:                      merged,
+					ConditionExpression:       aws.String(existingCondition),
+					ExpressionAttributeNames:  existingNames,
+					ExpressionAttributeValues: existingValues,
+				},
+			},
+			{
+				Delete: &types.Delete{
+					TableName:                 aws.String(table),
+					Key:                       key(item),
+					ConditionExpression:       aws.String(condition),
+					ExpressionAttributeNames:  names,
+					ExpressionAttributeValues: values,
+				},
+			},
+		},
+	})
+
+	var canceled *types.TransactionCanceledException
+	if errors.As(err, &canceled) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func key(item map[string]types.AttributeValue) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"id":        item["id"],
+		"projectId": item["projectId"],
+	}
+}
+
+func reread(ctx context.Context, table string, item map[string]types.AttributeValue) (map[string]types.AttributeValue, error) {
+	out, err := db.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(table),
+		Key:            key(item),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return out.Item, nil
+}
+
+// supersede disposes of a legacy item whose copy already exists under the
+// sub at target. It reports whether it did, which it does not if an item
+// changed since it was read.
+type supersede func(ctx context.Context, table, target string, item map[string]types.AttributeValue) (bool, error)
+
+// migrateItem moves the item to target, or supersedes it if target exists,
+// re-reading it whenever it changed underneath the migration.
+func migrateItem(ctx context.Context, table, target, sub string, item map[string]types.AttributeValue, supersede supersede, superseded *int, stats *Stats) error {
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		moved, changed, err := moveItem(ctx, table, target, sub, item)
+		if err != nil {
+			return err
+		}
+
+		if moved {
+			stats.Migrated++
+			return nil
+		}
+
+		if !changed {
+			done, err := supersede(ctx, table, target, item)
+			if err != nil {
+				return err
+			}
+			if done {
+				*superseded++
+				return nil
+			}
+		}
+
+		item, err = reread(ctx, table, item)
+		if err != nil {
+			return err
+		}
+
+		if item == nil {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("item kept changing after %d attempts", maxAttempts)
+}
+
+// moveHouses moves the houses of the legacy owner's project to the sub's,
+// then gives them the expiry of the sub's project.
+func moveHouses(ctx context.Context, table, legacyId, sub, projectId string, stats *Stats) error {
+	paginator := dynamodb.NewQueryPaginator(db, &dynamodb.QueryInput{
+		TableName:              aws.String(table),
+		KeyConditionExpression: aws.String("id = :id"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":id": &types.AttributeValueMemberS{Value: projectPrefix + legacyId + "#" + projectId},
+		},
+	})
+
+	target := projectPrefix + sub + "#" + projectId
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to query houses: %v", err)
+		}
+
+		for _, house := range page.Items {
+			if err := migrateItem(ctx, table, target, sub, house, deleteLegacy, &stats.Superseded, stats); err != nil {
+				return err
+			}
+		}
+	}
+
+	return syncExpiry(ctx, table, sub, projectId)
+}
+
+// syncExpiry gives the sub's houses of the project the project's expiry. A
+// project trashed or restored while its houses were still under the legacy
+// id only updated the houses under the sub.
+func syncExpiry(ctx context.Context, table, sub, projectId string) error {
+	out, err := db.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(table),
+		Key: map[string]types.AttributeValue{
+			"id":        &types.AttributeValueMemberS{Value: sub},
+			"projectId": &types.AttributeValueMemberS{Value: projectId},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get project: %v", err)
+	}
+	if out.Item == nil {
+		return nil
+	}
+	expiresAt, _ := out.Item["expiresAt"].(*types.AttributeValueMemberN)
+
+	paginator := dynamodb.NewQueryPaginator(db, &dynamodb.QueryInput{
+		TableName:              aws.String(table),
+		KeyConditionExpression: aws.String("id = :id"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":id": &types.AttributeValueMemberS{Value: projectPrefix + sub + "#" + projectId},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to query houses: %v", err)
+		}
+
+		for _, house := range page.Items {
+			current, _ := house["expiresAt"].(*types.AttributeValueMemberN)
+			if (current == nil) == (expiresAt == nil) && (current == nil || current.Value == expiresAt.Value) {
+				continue
+			}
+
+			input := &dynamodb.UpdateItemInput{
+				TableName:           aws.String(table),
+				Key:                 key(house),
+				UpdateExpression:    aws.String("REMOVE expiresAt"),
+				ConditionExpression: aws.String("attribute_exists(id)"),
+			}
+			if expiresAt != nil {
+				input.UpdateExpression = aws.String("SET expiresAt = :expiresAt")
+				input.ExpressionAttributeValues = map[string]types.AttributeValue{":expiresAt": expiresAt}
+			}
+
+			// A house deleted since the query is skipped.
+			_, err := db.UpdateItem(ctx, input)
+			var conditionErr *types.ConditionalCheckFailedException
+			if err != nil && !errors.As(err, &conditionErr) {
+				return fmt.Errorf("failed to update house expiry: %v", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// legacyProjectExists reports whether the legacy owner still has the project
+// under their legacy id.
+func legacyProjectExists(ctx context.Context, table, legacyId, projectId string) (bool, error) {
+	out, err := db.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(table),
+		Key: map[string]types.AttributeValue{
+			"id":        &types.AttributeValueMemberS{Value: legacyId},
+			"projectId": &types.AttributeValueMemberS{Value: projectId},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return false, err
+	}
+	return out.Item != nil, nil
+}
+
+func migrateTable(ctx context.Context, table string, ids map[string]string, dryRun bool) (*Stats, error) {
+	stats := &Stats{}
+
+	// housesMoved holds the legacy house partitions already moved, by their
+	// project or on their own, so that every house the scan finds in them
+	// does not move them again.
+	housesMoved := map[string]bool{}
+
+	paginator := dynamodb.NewScanPaginator(db, &dynamodb.ScanInput{
+		TableName: aws.String(table),
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return stats, fmt.Errorf("failed to scan %s: %v", table, err)
+		}
+
+		for _, item := range page.Items {
+			id, _ := item["id"].(*types.AttributeValueMemberS)
+			projectId, _ := item["projectId"].(*types.AttributeValueMemberS)
+			if id == nil || projectId == nil {
+				continue
+			}
+
+			prefix, ownerId, suffix := owner(id.Value)
+			sub, ok := ids[ownerId]
+			if !ok {
+				// Either already keyed by sub or owned by a user no longer in
+				// the pool; both are left alone.
+				stats.Unmatched++
+				continue
+			}
+			target := prefix + sub + suffix
+
+			if dryRun {
+				log.Printf("would move %s/%s to %s", id.Value, projectId.Value, target)
+				stats.Migrated++
+				continue
+			}
+
+			if err := migrateOwned(ctx, table, prefix, ownerId, sub, target, item, housesMoved, stats); err != nil {
+				log.Printf("Failed to migrate %s/%s: %v", id.Value, projectId.Value, err)
+				stats.Failed++
+			}
+		}
+	}
+
+	return stats, nil
+}
+
+// migrateOwned moves an item of the legacy owner ownerId, whose partition
+// key starts with prefix, to target.
+func migrateOwned(ctx context.Context, table, prefix, ownerId, sub, target string, item map[string]types.AttributeValue, housesMoved map[string]bool, stats *Stats) error {
+	switch prefix {
+	case profilePrefix:
+		return migrateItem(ctx, table, target, sub, item, mergeProfile, &stats.Merged, stats)
+
+	case projectPrefix:
+		// Houses move with their project. Only those whose project is no
+		// longer under the legacy id, because an earlier run moved it, move
+		// on their own.
+		partition := item["id"].(*types.AttributeValueMemberS).Value
+		if housesMoved[partition] {
+			return nil
+		}
+
+		projectId := strings.TrimPrefix(partition, projectPrefix+ownerId+"#")
+		exists, err := legacyProjectExists(ctx, table, ownerId, projectId)
+		if err != nil || exists {
+			return err
+		}
+
+		housesMoved[partition] = true
+		return moveHouses(ctx, table, ownerId, sub, projectId, stats)
+
+	default:
+		projectId := item["projectId"].(*types.AttributeValueMemberS).Value
+		if err := migrateItem(ctx, table, target, sub, item, deleteLegacy, &stats.Superseded, stats); err != nil {
+			return err
+		}
+
+		housesMoved[projectPrefix+ownerId+"#"+projectId] = true
+		return moveHouses(ctx, table, ownerId, sub, projectId, stats)
+	}
+}
+
+func main() {
+	table := flag.String("table", "UsersTable", "table holding the users' projects")
+	userPoolId := flag.String("user-pool", os.Getenv(userPoolEnv), "Cognito user pool to map email addresses to subs")
+	dryRun := flag.Bool("dry-run", false, "report what would be migrated without writing")
+	flag.Parse()
+
+	if *userPoolId == "" {
+		log.Fatalf("-user-pool or %s is required", userPoolEnv)
+	}
+
+	ctx := context.Background()
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		log.Fatalf("Failed to load SDK configuration: %v", err)
+	}
+	db = dynamodb.NewFromConfig(cfg)
+	cognito = cognitoidentityprovider.NewFromConfig(cfg)
+
+	ids, err := legacyIds(ctx, *userPoolId)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	stats, err := migrateTable(ctx, *table, ids, *dryRun)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("migrated %d, removed %d superseded, merged %d profiles, left %d, failed %d",
+		stats.Migrated, stats.Superseded, stats.Merged, stats.Unmatched, stats.Failed)
+
+	if stats.Failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,412 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+type item = map[string]types.AttributeValue
+
+// fakeDB is a table in memory. It evaluates the conditions the migration
+// writes with: attribute_exists, attribute_not_exists and equality, joined
+// with AND.
+type fakeDB struct {
+	items map[[2]string]item
+
+	// onTransact is called before the nth transaction is applied, to change
+	// the table the way a concurrent request would.
+	onTransact   func(f *fakeDB, n int)
+	transactions int
+}
+
+func newFakeDB(items []item) *fakeDB {
+	f := &fakeDB{items: map[[2]string]item{}}
+	for _, it := range items {
+		f.put(it)
+	}
+	return f
+}
+
+func keyOf(it item) [2]string {
+	return [2]string{it["id"].(*types.AttributeValueMemberS).Value, it["projectId"].(*types.AttributeValueMemberS).Value}
+}
+
+func copyItem(it item) item {
+	if it == nil {
+		return nil
+	}
+	c := make(item, len(it))
+	for k, v := range it {
+		c[k] = v
+	}
+	return c
+}
+
+func (f *fakeDB) put(it item) {
+	f.items[keyOf(it)] = copyItem(it)
+}
+
+func (f *fakeDB) get(key item) item {
+	return f.items[keyOf(key)]
+}
+
+// sorted returns copies of the items matching keep, ordered by key.
+func (f *fakeDB) sorted(keep func(item) bool) []item {
+	keys := make([][2]string, 0, len(f.items))
+	for k := range f.items {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i][0] < keys[j][0] || keys[i][0] == keys[j][0] && keys[i][1] < keys[j][1]
+	})
+
+	var out []item
+	for _, k := range keys {
+		if keep(f.items[k]) {
+			out = append(out, copyItem(f.items[k]))
+		}
+	}
+	return out
+}
+
+func holds(condition *string, names map[string]string, values item, it item) bool {
+	if condition == nil {
+		return true
+	}
+
+	name := func(n string) string {
+		if strings.HasPrefix(n, "#") {
+			return names[n]
+		}
+		return n
+	}
+
+	for _, part := range strings.Split(*condition, " AND ") {
+		switch {
+		case strings.HasPrefix(part, "attribute_exists("):
+			if _, ok := it[name(strings.TrimSuffix(strings.TrimPrefix(part, "attribute_exists("), ")"))]; !ok {
+				return false
+			}
+		case strings.HasPrefix(part, "attribute_not_exists("):
+			if _, ok := it[name(strings.TrimSuffix(strings.TrimPrefix(part, "attribute_not_exists("), ")"))]; ok {
+				return false
+			}
+		default:
+			left, right, ok := strings.Cut(part, " = ")
+			if !ok {
+				panic("unsupported condition " + part)
+			}
+			if !reflect.DeepEqual(it[name(left)], values[right]) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func (f *fakeDB) Scan(ctx context.Context, in *dynamodb.ScanInput, _ ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	return &dynamodb.ScanOutput{Items: f.sorted(func(item) bool { return true })}, nil
+}
+
+func (f *fakeDB) Query(ctx context.Context, in *dynamodb.QueryInput, _ ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	if aws.ToString(in.KeyConditionExpression) != "id = :id" {
+		return nil, fmt.Errorf("unsupported key condition %s", aws.ToString(in.KeyConditionExpression))
+	}
+	id := in.ExpressionAttributeValues[":id"]
+	return &dynamodb.QueryOutput{Items: f.sorted(func(it item) bool { return reflect.DeepEqual(it["id"], id) })}, nil
+}
+
+func (f *fakeDB) GetItem(ctx context.Context, in *dynamodb.GetItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	return &dynamodb.GetItemOutput{Item: copyItem(f.get(in.Key))}, nil
+}
+
+func (f *fakeDB) UpdateItem(ctx context.Context, in *dynamodb.UpdateItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	it := f.get(in.Key)
+	if it == nil || !holds(in.ConditionExpression, in.ExpressionAttributeNames, in.ExpressionAttributeValues, it) {
+		return nil, &types.ConditionalCheckFailedException{}
+	}
+
+	switch update := aws.ToString(in.UpdateExpression); update {
+	case "SET expiresAt = :expiresAt":
+		it["expiresAt"] = in.ExpressionAttributeValues[":expiresAt"]
+	case "REMOVE expiresAt":
+		delete(it, "expiresAt")
+	default:
+		return nil, fmt.Errorf("unsupported update %s", update)
+	}
+
+	return &dynamodb.UpdateItemOutput{}, nil
+}
+
+func (f *fakeDB) DeleteItem(ctx context.Context, in *dynamodb.DeleteItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	it := f.get(in.Key)
+	if !holds(in.ConditionExpression, in.ExpressionAttributeNames, in.ExpressionAttributeValues, it) {
+		return nil, &types.ConditionalCheckFailedException{}
+	}
+
+	delete(f.items, keyOf(in.Key))
+	return &dynamodb.DeleteItemOutput{}, nil
+}
+
+func (f *fakeDB) TransactWriteItems(ctx context.Context, in *dynamodb.TransactWriteItemsInput, _ ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+	f.transactions++
+	if f.onTransact != nil {
+		f.onTransact(f, f.transactions)
+	}
+
+	reasons := make([]types.CancellationReason, len(in.TransactItems))
+	failed := false
+	for i, w := range in.TransactItems {
+		code := "None"
+		switch {
+		case w.Put != nil:
+			if !holds(w.Put.ConditionExpression, w.Put.ExpressionAttributeNames, w.Put.ExpressionAttributeValues, f.get(w.Put.Item)) {
+				code = "ConditionalCheckFailed"
+			}
+		case w.Delete != nil:
+			if !holds(w.Delete.ConditionExpression, w.Delete.ExpressionAttributeNames, w.Delete.ExpressionAttributeValues, f.get(w.Delete.Key)) {
+				code = "ConditionalCheckFailed"
+			}
+		}
+		if code != "None" {
+			failed = true
+		}
+		reasons[i] = types.CancellationReason{Code: aws.String(code)}
+	}
+
+	if failed {
+		return nil, &types.TransactionCanceledException{CancellationReasons: reasons}
+	}
+
+	for _, w := range in.TransactItems {
+		switch {
+		case w.Put != nil:
+			f.put(w.Put.Item)
+		case w.Delete != nil:
+			delete(f.items, keyOf(w.Delete.Key))
+		}
+	}
+
+	return &dynamodb.TransactWriteItemsOutput{}, nil
+}
+
+func s(v string) types.AttributeValue { return &types.AttributeValueMemberS{Value: v} }
+func n(v string) types.AttributeValue { return &types.AttributeValueMemberN{Value: v} }
+func m(v item) types.AttributeValue   { return &types.AttributeValueMemberM{Value: v} }
+
+func project(ownerId, projectId string, attrs item) item {
+	it := item{"id": s(ownerId), "projectId": s(projectId)}
+	for k, v := range attrs {
+		it[k] = v
+	}
+	return it
+}
+
+func house(ownerId, projectId, houseId string, attrs item) item {
+	it := item{"id": s("PROJECT#" + ownerId + "#" + projectId), "projectId": s("HOUSE#" + houseId), "ownerId": s(ownerId)}
+	for k, v := range attrs {
+		it[k] = v
+	}
+	return it
+}
+
+func profile(ownerId string, settings item) item {
+	return item{"id": s("USER#" + ownerId), "projectId": s("PROFILE"), "settings": m(settings)}
+}
+
+func TestMigrateTable(t *testing.T) {
+	ids := map[string]string{"legacy": "sub"}
+
+	tests := []struct {
+		name       string
+		items      []item
+		onTransact func(f *fakeDB, n int)
+		want       []item
+		stats      Stats
+	}{
+		{
+			name: "moved",
+			items: []item{
+				project("legacy", "p1", item{"project": m(item{"Title": s("Home")})}),
+				house("legacy", "p1", "h1", item{"Address": s("1 Main St")}),
+				house("legacy", "p1", "h2", item{"Address": s("2 Main St")}),
+				profile("legacy", item{"Language": s("en")}),
+				project("other", "p9", nil),
+			},
+			want: []item{
+				project("sub", "p1", item{"project": m(item{"Title": s("Home")})}),
+				house("sub", "p1", "h1", item{"Address": s("1 Main St")}),
+				house("sub", "p1", "h2", item{"Address": s("2 Main St")}),
+				profile("sub", item{"Language": s("en")}),
+				project("other", "p9", nil),
+			},
+			stats: Stats{Migrated: 4, Unmatched: 1},
+		},
+		{
+			name: "superseded",
+			items: []item{
+				project("legacy", "p1", item{"project": m(item{"Title": s("Old")})}),
+				project("sub", "p1", item{"project": m(item{"Title": s("New")})}),
+			},
+			want: []item{
+				project("sub", "p1", item{"project": m(item{"Title": s("New")})}),
+			},
+			stats: Stats{Superseded: 1, Unmatched: 1},
+		},
+		{
+			name: "changed during move",
+			items: []item{
+				project("legacy", "p1", item{"project": m(item{"Title": s("Old")})}),
+			},
+			onTransact: func(f *fakeDB, i int) {
+				if i == 1 {
+					f.put(project("legacy", "p1", item{"project": m(item{"Title": s("Renamed")})}))
+				}
+			},
+			want: []item{
+				project("sub", "p1", item{"project": m(item{"Title": s("Renamed")})}),
+			},
+			stats: Stats{Migrated: 1},
+		},
+		{
+			name: "profile collision",
+			items: []item{
+				profile("legacy", item{"Language": s("en"), "Currency": s("EUR")}),
+				profile("sub", item{"Language": s("es")}),
+			},
+			want: []item{
+				profile("sub", item{"Language": s("es"), "Currency": s("EUR")}),
+			},
+			stats: Stats{Merged: 1, Unmatched: 1},
+		},
+		{
+			name: "profile collision changed during merge",
+			items: []item{
+				profile("legacy", item{"Currency": s("EUR")}),
+				profile("sub", item{"Language": s("es")}),
+			},
+			onTransact: func(f *fakeDB, i int) {
+				// The first transaction tries to move the legacy profile, the
+				// second to merge it, while the sub's profile changes.
+				if i == 2 {
+					f.put(profile("sub", item{"Language": s("es"), "AreaUnit": s("m2")}))
+				}
+			},
+			want: []item{
+				profile("sub", item{"Language": s("es"), "AreaUnit": s("m2"), "Currency": s("EUR")}),
+			},
+			stats: Stats{Merged: 1, Unmatched: 1},
+		},
+		{
+			name: "trashed between the project and its houses",
+			items: []item{
+				project("legacy", "p1", nil),
+				house("legacy", "p1", "h1", nil),
+			},
+			onTransact: func(f *fakeDB, i int) {
+				// Trashing the moved project only reaches the houses
+				// already under the sub, which are none yet.
+				if i == 2 {
+					f.put(project("sub", "p1", item{"deletedAt": n("50"), "expiresAt": n("100")}))
+				}
+			},
+			want: []item{
+				project("sub", "p1", item{"deletedAt": n("50"), "expiresAt": n("100")}),
+				house("sub", "p1", "h1", item{"expiresAt": n("100")}),
+			},
+			stats: Stats{Migrated: 2},
+		},
+		{
+			name: "restored between the project and its houses",
+			items: []item{
+				project("legacy", "p1", item{"deletedAt": n("50"), "expiresAt": n("100")}),
+				house("legacy", "p1", "h1", item{"expiresAt": n("100")}),
+			},
+			onTransact: func(f *fakeDB, i int) {
+				if i == 2 {
+					f.put(project("sub", "p1", nil))
+				}
+			},
+			want: []item{
+				project("sub", "p1", nil),
+				house("sub", "p1", "h1", nil),
+			},
+			stats: Stats{Migrated: 2},
+		},
+		{
+			name: "houses left behind by an earlier run",
+			items: []item{
+				project("sub", "p1", item{"deletedAt": n("50"), "expiresAt": n("100")}),
+				house("legacy", "p1", "h1", nil),
+			},
+			want: []item{
+				project("sub", "p1", item{"deletedAt": n("50"), "expiresAt": n("100")}),
+				house("sub", "p1", "h1", item{"expiresAt": n("100")}),
+			},
+			stats: Stats{Migrated: 1, Unmatched: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeDB(tt.items)
+			fake.onTransact = tt.onTransact
+			db = fake
+
+			stats, err := migrateTable(context.Background(), "UsersTable", ids, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if *stats != tt.stats {
+				t.Errorf("stats %+v, want %+v", *stats, tt.stats)
+			}
+
+			got := fake.sorted(func(item) bool { return true })
+			want := newFakeDB(tt.want).sorted(func(item) bool { return true })
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("table:\n%s\nwant:\n%s", describe(got), describe(want))
+			}
+		})
+	}
+}
+
+func describe(items []item) string {
+	var b strings.Builder
+	for _, it := range items {
+		fmt.Fprintf(&b, "  %s\n", describeValue(&types.AttributeValueMemberM{Value: it}))
+	}
+	return b.String()
+}
+
+func describeValue(v types.AttributeValue) string {
+	switch v := v.(type) {
+	case *types.AttributeValueMemberS:
+		return fmt.Sprintf("%q", v.Value)
+	case *types.AttributeValueMemberN:
+		return v.Value
+	case *types.AttributeValueMemberM:
+		names := make([]string, 0, len(v.Value))
+		for k := range v.Value {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+
+		parts := make([]string, len(names))
+		for i, k := range names {
+			parts[i] = k + ": " + describeValue(v.Value[k])
+		}
+		return "{" + strings.Join(parts, ", ") + "}"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
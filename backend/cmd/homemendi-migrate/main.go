@@ -0,0 +1,102 @@
+// Command homemendi-migrate brings UsersTable items up to the schema version
+// the lambdas write. See the migrate package for how a run works.
+//
+// Progress is checkpointed, so running the command again after it was
+// interrupted resumes the scan. Pass -dry-run first to see what would change.
+//
+//	homemendi-migrate [-dry-run] [-to 4] [-restart]
+//
+// To try a migration against DynamoDB Local, start it and point the command
+// at it. Any credentials are accepted:
+//
+//	docker run -p 8000:8000 amazon/dynamodb-local
+//	AWS_REGION=us-east-1 AWS_ACCESS_KEY_ID=local AWS_SECRET_ACCESS_KEY=local \
+//		homemendi-migrate -endpoint http://localhost:8000
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/hnucamendi/house-hunting/backend/lib/migrate"
+)
+
+func main() {
+	table := flag.String("table", "UsersTable", "table holding the users' projects")
+	target := flag.Int("to", 0, "schema version to migrate to; defaults to the latest")
+	dryRun := flag.Bool("dry-run", false, "report what would change without writing")
+	restart := flag.Bool("restart", false, "ignore the saved checkpoint and scan from the start")
+	endpoint := flag.String("endpoint", "", "DynamoDB endpoint, such as http://localhost:8000 for DynamoDB Local")
+	checkpointTable := flag.String("checkpoint-table", "", "table to keep checkpoints in, keyed by runId")
+	checkpointFile := flag.String("checkpoint-file", "homemendi-migrate.json", "file to keep checkpoints in when no -checkpoint-table is given")
+	pageSize := flag.Int("page-size", 0, "items to read per scan page")
+	jsonReport := flag.Bool("json", false, "print the report as JSON")
+	flag.Parse()
+
+	ctx := context.Background()
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		log.Fatalf("Failed to load SDK configuration: %v", err)
+	}
+
+	db := dynamodb.NewFromConfig(cfg, func(o *dynamodb.Options) {
+		if *endpoint != "" {
+			o.BaseEndpoint = aws.String(*endpoint)
+		}
+	})
+
+	var checkpoints migrate.Checkpoints = migrate.NewFileCheckpoints(*checkpointFile)
+	if *checkpointTable != "" {
+		checkpoints = migrate.NewTableCheckpoints(db, *checkpointTable)
+	}
+
+	report, err := migrate.New(db, checkpoints, log.Printf).Run(ctx, migrate.Options{
+		Table:    *table,
+		Target:   *target,
+		DryRun:   *dryRun,
+		Restart:  *restart,
+		PageSize: int32(*pageSize),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *jsonReport {
+		b, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			log.Fatalf("Failed to marshal report: %v", err)
+		}
+		fmt.Println(string(b))
+	} else {
+		printReport(report)
+	}
+
+	// A run that resumed a scan fails if an earlier part of the scan did.
+	if report.TotalFailed > 0 {
+		os.Exit(1)
+	}
+}
+
+func printReport(report *migrate.Report) {
+	for _, change := range report.Changes {
+		fmt.Println(change.Key)
+		for _, diff := range change.Diffs {
+			fmt.Println("  " + diff)
+		}
+		if len(change.Created) > 0 {
+			fmt.Println("  creates " + strings.Join(change.Created, ", "))
+		}
+	}
+
+	log.Printf("%s: scanned %d, already current %d, migrated %d, created %d, failed %d (%d in the whole scan)",
+		report.RunId, report.Scanned, report.Current, report.Migrated, report.Created, report.Failed, report.TotalFailed)
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
+	cognitotypes "github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/hnucamendi/house-hunting/backend/lib/audit"
+	"github.com/hnucamendi/house-hunting/backend/lib/domain"
+	"github.com/hnucamendi/house-hunting/backend/lib/handler"
+	"github.com/hnucamendi/house-hunting/backend/lib/identity"
+	"github.com/hnucamendi/house-hunting/backend/lib/request"
+	"github.com/hnucamendi/house-hunting/backend/lib/response"
+)
+
+var (
+	db      *dynamodb.Client
+	cognito *cognitoidentityprovider.Client
+)
+
+func init() {
+	cfg, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		log.Fatalf("Failed to load SDK configuration: %v", err)
+	}
+	db = dynamodb.NewFromConfig(cfg)
+	cognito = cognitoidentityprovider.NewFromConfig(cfg)
+}
+
+const (
+	// userPoolEnv names the Cognito user pool the disabled user belongs to.
+	userPoolEnv = "HOMEMENDI_COGNITO_USER_POOL_ID"
+
+	revocationsTable = "TokenRevocationsTable"
+
+	apiKeysTable = "ApiKeysTable"
+	ownerIndex   = "ownerSub-index"
+)
+
+type DisableUserRequest struct {
+	Username string `json:"username"`
+}
+
+func (r *DisableUserRequest) Validate() *domain.ValidationError {
+	if r.Username == "" {
+		return &domain.ValidationError{Field: "username", Key: "required"}
+	}
+	return nil
+}
+
+var errUserNotFound = errors.New("user not found")
+
+// userSub returns the sub of the user, or errUserNotFound if the user pool
+// has no such user.
+func userSub(ctx context.Context, userPoolId, username string) (string, error) {
+	out, err := cognito.AdminGetUser(ctx, &cognitoidentityprovider.AdminGetUserInput{
+		UserPoolId: aws.String(userPoolId),
+		Username:   aws.String(username),
+	})
+	var notFound *cognitotypes.UserNotFoundException
+	if errors.As(err, &notFound) {
+		return "", errUserNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get user: %v", err)
+	}
+
+	for _, attr := range out.UserAttributes {
+		if aws.ToString(attr.Name) == "sub" {
+			return aws.ToString(attr.Value), nil
+		}
+	}
+
+	return "", fmt.Errorf("user %s has no sub", username)
+}
+
+// disableUser blocks new sign-ins, invalidates the user's refresh tokens,
+// revokes the tokens they already hold, which would otherwise stay valid
+// until they expire, and deletes their API keys.
+func disableUser(ctx context.Context, userPoolId, username, sub string) error {
+	_, err := cognito.AdminDisableUser(ctx, &cognitoidentityprovider.AdminDisableUserInput{
+		UserPoolId: aws.String(userPoolId),
+		Username:   aws.String(username),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to disable user: %v", err)
+	}
+
+	_, err = cognito.AdminUserGlobalSignOut(ctx, &cognitoidentityprovider.AdminUserGlobalSignOutInput{
+		UserPoolId: aws.String(userPoolId),
+		Username:   aws.String(username),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to sign out user: %v", err)
+	}
+
+	_, err = db.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(revocationsTable),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: "USER#" + sub},
+		},
+		UpdateExpression: aws.String("SET tokensIssuedBefore = :now"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":now": &types.AttributeValueMemberN{Value: strconv.FormatInt(time.Now().Unix(), 10)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to revoke tokens: %v", err)
+	}
+
+	return deleteAPIKeys(ctx, sub)
+}
+
+func deleteAPIKeys(ctx context.Context, sub string) error {
+	paginator := dynamodb.NewQueryPaginator(db, &dynamodb.QueryInput{
+		TableName:              aws.String(apiKeysTable),
+		IndexName:              aws.String(ownerIndex),
+		KeyConditionExpression: aws.String("ownerSub = :sub"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":sub": &types.AttributeValueMemberS{Value: sub},
+		},
+		ProjectionExpression: aws.String("keyId"),
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to query API keys: %v", err)
+		}
+
+		for _, item := range page.Items {
+			_, err := db.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+				TableName: aws.String(apiKeysTable),
+				Key: map[string]types.AttributeValue{
+					"keyId": item["keyId"],
+				},
+			})
+			if err != nil {
+				return fmt.Errorf("failed to delete API key: %v", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func HandleRequest(ctx context.Context, req *request.Request, res *response.Responder) *events.APIGatewayV2HTTPResponse {
+	userPoolId := os.Getenv(userPoolEnv)
+	if userPoolId == "" {
+		return res.Internal(fmt.Errorf("%s is not configured", userPoolEnv))
+	}
+
+	var body DisableUserRequest
+	if resp := req.Decode(&body); resp != nil {
+		return resp
+	}
+
+	if body.Username == req.Identity.Username {
+		return res.BadRequest("cannot_disable_self")
+	}
+
+	sub, err := userSub(ctx, userPoolId, body.Username)
+	if err == errUserNotFound {
+		log.Printf("User %s not found", body.Username)
+		return res.NotFound("user_not_found")
+	}
+	if err != nil {
+		return res.Internal(err)
+	}
+
+	err = audit.Record(ctx, db, req, "admin.disableUser", map[string]string{
+		"username": body.Username,
+		"sub":      sub,
+	})
+	if err != nil {
+		return res.Internal(fmt.Errorf("failed to record audit entry: %v", err))
+	}
+
+	err = disableUser(ctx, userPoolId, body.Username, sub)
+	if err != nil {
+		return res.Internal(fmt.Errorf("failed to disable user: %v", err))
+	}
+
+	return res.NoContent()
+}
+
+func main() {
+	handler.Start(handler.RequireRole(identity.RoleAdmin, HandleRequest))
+}
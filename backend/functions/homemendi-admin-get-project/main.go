@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/hnucamendi/house-hunting/backend/lib/audit"
+	"github.com/hnucamendi/house-hunting/backend/lib/handler"
+	"github.com/hnucamendi/house-hunting/backend/lib/identity"
+	"github.com/hnucamendi/house-hunting/backend/lib/repository"
+	"github.com/hnucamendi/house-hunting/backend/lib/request"
+	"github.com/hnucamendi/house-hunting/backend/lib/response"
+)
+
+var (
+	db   *dynamodb.Client
+	repo *repository.Repository
+)
+
+func init() {
+	cfg, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		log.Fatalf("Failed to load SDK configuration: %v", err)
+	}
+	db = dynamodb.NewFromConfig(cfg)
+	repo = repository.New(db)
+}
+
+// HandleRequest returns any user's project so support can see what the user
+// sees. userId is the project's partition key as listed by GET /admin/users.
+func HandleRequest(ctx context.Context, req *request.Request, res *response.Responder) *events.APIGatewayV2HTTPResponse {
+	userId := req.Query("userId")
+	projectId := req.Query("projectId")
+	if userId == "" || projectId == "" {
+		return res.BadRequest("missing_user_or_project_id")
+	}
+
+	err := audit.Record(ctx, db, req, "admin.getProject", map[string]string{
+		"userId":    userId,
+		"projectId": projectId,
+	})
+	if err != nil {
+		return res.Internal(fmt.Errorf("failed to record audit entry: %v", err))
+	}
+
+	// Administrators see projects in the trash too.
+	user, err := repo.FindProject(ctx, []string{userId}, projectId, true)
+	if err == repository.ErrNotFound {
+		return res.NotFound("project_not_found")
+	}
+	if err != nil {
+		return res.Internal(fmt.Errorf("failed to get item: %v", err))
+	}
+
+	user.Project.HouseEntries, err = repo.ListHouses(ctx, user)
+	if err != nil {
+		return res.Internal(fmt.Errorf("failed to get houses: %v", err))
+	}
+
+	return res.JSON(200, user)
+}
+
+func main() {
+	handler.Start(handler.RequireRole(identity.RoleAdmin, HandleRequest))
+}
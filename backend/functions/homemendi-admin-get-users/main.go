@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
+	cognitotypes "github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/hnucamendi/house-hunting/backend/lib/audit"
+	"github.com/hnucamendi/house-hunting/backend/lib/domain"
+	"github.com/hnucamendi/house-hunting/backend/lib/handler"
+	"github.com/hnucamendi/house-hunting/backend/lib/identity"
+	"github.com/hnucamendi/house-hunting/backend/lib/repository"
+	"github.com/hnucamendi/house-hunting/backend/lib/request"
+	"github.com/hnucamendi/house-hunting/backend/lib/response"
+)
+
+var (
+	db      *dynamodb.Client
+	repo    *repository.Repository
+	cognito *cognitoidentityprovider.Client
+)
+
+func init() {
+	cfg, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		log.Fatalf("Failed to load SDK configuration: %v", err)
+	}
+	db = dynamodb.NewFromConfig(cfg)
+	repo = repository.New(db)
+	cognito = cognitoidentityprovider.NewFromConfig(cfg)
+}
+
+// userPoolEnv names the Cognito user pool whose users are listed.
+const userPoolEnv = "HOMEMENDI_COGNITO_USER_POOL_ID"
+
+type UserSummary struct {
+	Username     string    `json:"username"`
+	Sub          string    `json:"sub"`
+	Email        string    `json:"email"`
+	Enabled      bool      `json:"enabled"`
+	Status       string    `json:"status"`
+	CreatedAt    time.Time `json:"createdAt"`
+	ProjectCount int       `json:"projectCount"`
+}
+
+type UsersPage struct {
+	Users     []UserSummary `json:"users"`
+	NextToken string        `json:"nextToken,omitempty"`
+}
+
+func summarize(u cognitotypes.UserType) UserSummary {
+	summary := UserSummary{
+		Username:  aws.ToString(u.Username),
+		Enabled:   u.Enabled,
+		Status:    string(u.UserStatus),
+		CreatedAt: aws.ToTime(u.UserCreateDate),
+	}
+
+	for _, attr := range u.Attributes {
+		switch aws.ToString(attr.Name) {
+		case "sub":
+			summary.Sub = aws.ToString(attr.Value)
+		case "email":
+			summary.Email = aws.ToString(attr.Value)
+		}
+	}
+
+	return summary
+}
+
+// owners returns the ids the user's projects are stored under. Projects not
+// yet migrated off the legacy email-hash id count too.
+func owners(summary UserSummary) []string {
+	ids := []string{summary.Sub}
+	if summary.Email != "" {
+		ids = append(ids, domain.LegacyUserId(summary.Email))
+	}
+	return ids
+}
+
+func HandleRequest(ctx context.Context, req *request.Request, res *response.Responder) *events.APIGatewayV2HTTPResponse {
+	userPoolId := os.Getenv(userPoolEnv)
+	if userPoolId == "" {
+		return res.Internal(fmt.Errorf("%s is not configured", userPoolEnv))
+	}
+
+	input := &cognitoidentityprovider.ListUsersInput{
+		UserPoolId: aws.String(userPoolId),
+		Limit:      aws.Int32(60),
+	}
+	if token := req.Query("nextToken"); token != "" {
+		input.PaginationToken = aws.String(token)
+	}
+
+	users, err := cognito.ListUsers(ctx, input)
+	if err != nil {
+		return res.Internal(fmt.Errorf("failed to list users: %v", err))
+	}
+
+	page := UsersPage{
+		Users:     make([]UserSummary, 0, len(users.Users)),
+		NextToken: aws.ToString(users.PaginationToken),
+	}
+	var ids []string
+	for _, u := range users.Users {
+		summary := summarize(u)
+		page.Users = append(page.Users, summary)
+		ids = append(ids, owners(summary)...)
+	}
+
+	// Only the users on this page are counted, one query per id.
+	counts, err := repo.CountProjects(ctx, ids)
+	if err != nil {
+		return res.Internal(fmt.Errorf("failed to count projects: %v", err))
+	}
+	for i := range page.Users {
+		for _, id := range owners(page.Users[i]) {
+			page.Users[i].ProjectCount += counts[id]
+		}
+	}
+
+	err = audit.Record(ctx, db, req, "admin.listUsers", map[string]string{
+		"nextToken": req.Query("nextToken"),
+	})
+	if err != nil {
+		return res.Internal(fmt.Errorf("failed to record audit entry: %v", err))
+	}
+
+	return res.JSON(200, page)
+}
+
+func main() {
+	handler.Start(handler.RequireRole(identity.RoleAdmin, HandleRequest))
+}
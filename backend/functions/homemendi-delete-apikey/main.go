@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/hnucamendi/house-hunting/backend/lib/handler"
+	"github.com/hnucamendi/house-hunting/backend/lib/request"
+	"github.com/hnucamendi/house-hunting/backend/lib/response"
+)
+
+var db *dynamodb.Client
+
+func init() {
+	cfg, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		log.Fatalf("Failed to load SDK configuration: %v", err)
+	}
+	db = dynamodb.NewFromConfig(cfg)
+}
+
+const apiKeysTable = "ApiKeysTable"
+
+func HandleRequest(ctx context.Context, req *request.Request, res *response.Responder) *events.APIGatewayV2HTTPResponse {
+	keyId := req.Query("keyId")
+	if keyId == "" {
+		return res.BadRequest("key_id_required")
+	}
+
+	// Deleting the item revokes the key: the authorizer looks every key up on
+	// each request. The condition keeps users from deleting each other's keys
+	// and reports a missing key the same way.
+	_, err := db.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(apiKeysTable),
+		Key: map[string]types.AttributeValue{
+			"keyId": &types.AttributeValueMemberS{Value: keyId},
+		},
+		ConditionExpression: aws.String("ownerSub = :sub"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":sub": &types.AttributeValueMemberS{Value: req.Identity.Sub},
+		},
+	})
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return res.NotFound("api_key_not_found")
+		}
+
+		return res.Internal(fmt.Errorf("failed to delete API key: %v", err))
+	}
+
+	return res.NoContent()
+}
+
+func main() {
+	handler.Start(HandleRequest)
+}
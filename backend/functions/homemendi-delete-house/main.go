@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/hnucamendi/house-hunting/backend/lib/api"
+	"github.com/hnucamendi/house-hunting/backend/lib/handler"
+	"github.com/hnucamendi/house-hunting/backend/lib/repository"
+)
+
+var (
+	app *api.API
+)
+
+func init() {
+	cfg, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		log.Fatalf("Failed to load SDK configuration: %v", err)
+	}
+	app = api.New(repository.New(dynamodb.NewFromConfig(cfg)), nil)
+}
+
+func main() {
+	handler.Start(app.DeleteHouse)
+}